@@ -0,0 +1,82 @@
+package throughputlb
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// BackoffConfig controls the exponential backoff with jitter used by next
+// while it waits for an address to free up capacity. It is modeled on
+// grpc.DefaultBackoffConfig.
+type BackoffConfig struct {
+	// BaseDelay is the amount of time to wait before retrying after the
+	// first failed attempt.
+	BaseDelay time.Duration
+
+	// Factor is the multiplier applied to the delay after each attempt.
+	Factor float64
+
+	// Jitter is the fraction of the computed delay to randomize by, e.g.
+	// 0.2 means the delay is scaled by a random value in [0.8, 1.2].
+	Jitter float64
+
+	// MaxDelay caps the computed delay regardless of attempt count.
+	MaxDelay time.Duration
+}
+
+// DefaultBackoffConfig is the BackoffConfig used when no WithBackoff option
+// is supplied, matching grpc.DefaultBackoffConfig.
+var DefaultBackoffConfig = BackoffConfig{
+	BaseDelay: 10 * time.Millisecond,
+	Factor:    1.6,
+	Jitter:    0.2,
+	MaxDelay:  time.Second,
+}
+
+// delay returns the backoff duration for the given attempt (0-indexed)
+// before jitter is applied by the caller.
+func (c BackoffConfig) delay(attempt int) time.Duration {
+	if attempt == 0 {
+		return c.BaseDelay
+	}
+
+	backoff, max := float64(c.BaseDelay), float64(c.MaxDelay)
+	for backoff < max && attempt > 0 {
+		backoff *= c.Factor
+		attempt--
+	}
+
+	if backoff > max {
+		backoff = max
+	}
+
+	return time.Duration(backoff)
+}
+
+// jitteredDelay applies cfg's exponential backoff plus jitter sourced from
+// rnd for the given attempt (0-indexed). Shared by
+// ThroughputLoadBalancer.next's wait loop and the dns/srv resolvers'
+// re-resolution retries.
+func jitteredDelay(cfg BackoffConfig, rnd *rand.Rand, attempt int) time.Duration {
+	backoff := float64(cfg.delay(attempt))
+	jitter := 1 + cfg.Jitter*(2*rnd.Float64()-1)
+
+	return time.Duration(backoff * jitter)
+}
+
+// backoffWait sleeps for cfg's jittered backoff on attempt (0-indexed),
+// reporting false if ctx is done before the delay elapses. Used by the
+// dns/srv resolvers and the health checker's reconnect loop to retry after
+// a failure without every instance hammering the remote in lockstep.
+func backoffWait(ctx context.Context, cfg BackoffConfig, rnd *rand.Rand, attempt int) bool {
+	timer := time.NewTimer(jitteredDelay(cfg, rnd, attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
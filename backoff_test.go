@@ -0,0 +1,49 @@
+package throughputlb
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestBackoffConfigDelay(t *testing.T) {
+	cfg := DefaultBackoffConfig
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{1, 16 * time.Millisecond},
+		{2, 25600 * time.Microsecond},
+	}
+
+	for _, c := range cases {
+		if got := cfg.delay(c.attempt); got != c.want {
+			t.Errorf("delay(%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestBackoffConfigDelayCapsAtMaxDelay(t *testing.T) {
+	cfg := DefaultBackoffConfig
+
+	if got := cfg.delay(100); got != cfg.MaxDelay {
+		t.Errorf("delay(100) = %v, want MaxDelay %v", got, cfg.MaxDelay)
+	}
+}
+
+func TestJitteredDelayWithinBounds(t *testing.T) {
+	cfg := DefaultBackoffConfig
+	rnd := rand.New(rand.NewSource(1))
+
+	base := cfg.delay(3)
+	low := time.Duration(float64(base) * (1 - cfg.Jitter))
+	high := time.Duration(float64(base) * (1 + cfg.Jitter))
+
+	for i := 0; i < 1000; i++ {
+		if d := jitteredDelay(cfg, rnd, 3); d < low || d > high {
+			t.Fatalf("jitteredDelay = %v, want in [%v, %v]", d, low, high)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package throughputlb
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthCheckConfig holds the parameters passed to WithHealthCheck.
+type healthCheckConfig struct {
+	service  string
+	interval time.Duration
+}
+
+// watch dials addr and tracks its grpc.health.v1.Health status for as long
+// as ctx is alive, marking healthy/unhealthy as reports come in. It prefers
+// the streaming Watch RPC, redialing and re-watching with backoff whenever
+// the stream ends (a transient disconnect on the health channel shouldn't
+// strand the address unhealthy for the rest of its life), and falls back
+// to polling Check on interval for good once a server reports Watch itself
+// unimplemented.
+func (c *healthCheckConfig) watch(ctx context.Context, a *address) {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	for attempt := 0; ; attempt++ {
+		conn, err := grpc.DialContext(ctx, a.Addr, grpc.WithInsecure(), grpc.WithBlock())
+		if err != nil {
+			a.markUnhealthy()
+
+			if !backoffWait(ctx, DefaultBackoffConfig, rnd, attempt) {
+				return
+			}
+
+			continue
+		}
+
+		client := healthpb.NewHealthClient(conn)
+		req := &healthpb.HealthCheckRequest{Service: c.service}
+
+		handled := c.watchStream(ctx, client, req, a)
+		if !handled {
+			c.pollCheck(ctx, client, req, a)
+			conn.Close()
+
+			return
+		}
+
+		conn.Close()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !backoffWait(ctx, DefaultBackoffConfig, rnd, attempt) {
+			return
+		}
+	}
+}
+
+// watchStream consumes a Health/Watch stream until it errors or ctx is
+// done. It returns false if Watch itself is unimplemented, signalling the
+// caller to fall back to polling Check.
+func (c *healthCheckConfig) watchStream(ctx context.Context, client healthpb.HealthClient, req *healthpb.HealthCheckRequest, a *address) bool {
+	stream, err := client.Watch(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		return false
+	}
+	if err != nil {
+		a.markUnhealthy()
+		return true
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if status.Code(err) == codes.Unimplemented {
+			return false
+		}
+		if err != nil {
+			a.markUnhealthy()
+			return true
+		}
+
+		c.record(resp.Status, a)
+	}
+}
+
+// pollCheck periodically calls the unary Check RPC, used when the server
+// doesn't support the Watch streaming API.
+func (c *healthCheckConfig) pollCheck(ctx context.Context, client healthpb.HealthClient, req *healthpb.HealthCheckRequest, a *address) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := client.Check(ctx, req)
+		if err != nil {
+			a.markUnhealthy()
+		} else {
+			c.record(resp.Status, a)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *healthCheckConfig) record(st healthpb.HealthCheckResponse_ServingStatus, a *address) {
+	if st == healthpb.HealthCheckResponse_SERVING {
+		a.markHealthy()
+		return
+	}
+
+	// NOT_SERVING, SERVICE_UNKNOWN, UNKNOWN all mean "don't route here".
+	a.markUnhealthy()
+}
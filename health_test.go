@@ -0,0 +1,53 @@
+package throughputlb
+
+import (
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthCheckConfigRecordTransitions(t *testing.T) {
+	c := &healthCheckConfig{service: "svc"}
+	a := &address{state: stateUp}
+
+	c.record(healthpb.HealthCheckResponse_SERVING, a)
+	if a.unhealthy {
+		t.Fatal("SERVING should mark the address healthy")
+	}
+
+	c.record(healthpb.HealthCheckResponse_NOT_SERVING, a)
+	if !a.unhealthy {
+		t.Fatal("NOT_SERVING should mark the address unhealthy")
+	}
+
+	c.record(healthpb.HealthCheckResponse_SERVING, a)
+	if a.unhealthy {
+		t.Fatal("a subsequent SERVING report should clear unhealthy again")
+	}
+
+	c.record(healthpb.HealthCheckResponse_SERVICE_UNKNOWN, a)
+	if !a.unhealthy {
+		t.Fatal("SERVICE_UNKNOWN should mark the address unhealthy")
+	}
+}
+
+// TestAddressIsUpRequiresHealthAndTransport guards the chunk0-5 requirement
+// that application-level health is tracked separately from, and ANDed with,
+// transport connectivity.
+func TestAddressIsUpRequiresHealthAndTransport(t *testing.T) {
+	a := &address{state: stateUp}
+	if !a.isUp() {
+		t.Fatal("up and healthy address should report isUp")
+	}
+
+	a.markUnhealthy()
+	if a.isUp() {
+		t.Fatal("unhealthy address should not report isUp even though transport is up")
+	}
+
+	a.markHealthy()
+	a.state = stateDown
+	if a.isUp() {
+		t.Fatal("down address should not report isUp even though healthy")
+	}
+}
@@ -0,0 +1,232 @@
+package throughputlb
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errNoAddrAvailable is returned by a Picker when no up address exists to
+// choose from. next treats it the same as it treats a nil address: no
+// capacity, try again or fail depending on BlockingWait.
+var errNoAddrAvailable = errors.New("no up address available")
+
+// Picker selects one address to serve the next request out of the full set
+// of addresses known to the ThroughputLoadBalancer. Implementations must
+// filter out addresses reported down by isUp, or already at maxRequests by
+// hasCapacity, themselves; next still re-checks via claim when a picked
+// address turns out to be full by the time it claims it, but a Picker that
+// ignores capacity will send next back to claim on a full address far more
+// often than necessary.
+type Picker interface {
+	Pick(addrs []*address) (*address, error)
+}
+
+// upAddrs returns the addresses that are both up and have spare capacity,
+// despite the name: transport/health state alone isn't enough to be a
+// sensible pick, an address pegged at maxRequests isn't either.
+func upAddrs(addrs []*address) []*address {
+	up := make([]*address, 0, len(addrs))
+	for _, a := range addrs {
+		if a.isUp() && a.hasCapacity() {
+			up = append(up, a)
+		}
+	}
+
+	return up
+}
+
+// LeastLoadedPicker selects the up address with the fewest active requests.
+// This is the original, and still default, ThroughputLoadBalancer strategy.
+type LeastLoadedPicker struct{}
+
+func (LeastLoadedPicker) Pick(addrs []*address) (*address, error) {
+	var chosen *address
+	lowest := -1
+
+	for _, a := range addrs {
+		if !a.isUp() || !a.hasCapacity() {
+			continue
+		}
+
+		if c := a.capacity(); chosen == nil || c < lowest {
+			chosen = a
+			lowest = c
+		}
+	}
+
+	if chosen == nil {
+		return nil, errNoAddrAvailable
+	}
+
+	return chosen, nil
+}
+
+// RoundRobinPicker cycles through the up addresses in order using an atomic
+// counter, independent of current load.
+type RoundRobinPicker struct {
+	counter uint64
+}
+
+func (p *RoundRobinPicker) Pick(addrs []*address) (*address, error) {
+	up := upAddrs(addrs)
+	if len(up) == 0 {
+		return nil, errNoAddrAvailable
+	}
+
+	i := atomic.AddUint64(&p.counter, 1)
+
+	return up[int(i)%len(up)], nil
+}
+
+// PickFirstPicker always selects the first up address with spare capacity,
+// falling back to the next one when the first is down or at maxRequests.
+type PickFirstPicker struct{}
+
+func (PickFirstPicker) Pick(addrs []*address) (*address, error) {
+	for _, a := range addrs {
+		if a.isUp() && a.hasCapacity() {
+			return a, nil
+		}
+	}
+
+	return nil, errNoAddrAvailable
+}
+
+// PowerOfTwoChoicesPicker samples two random up addresses and returns the
+// one with fewer active requests. It approaches the load distribution of
+// LeastLoadedPicker in O(1) instead of O(N) and is well known to avoid the
+// herd behavior plain random selection exhibits under contention.
+type PowerOfTwoChoicesPicker struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func NewPowerOfTwoChoicesPicker() *PowerOfTwoChoicesPicker {
+	return &PowerOfTwoChoicesPicker{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (p *PowerOfTwoChoicesPicker) Pick(addrs []*address) (*address, error) {
+	up := upAddrs(addrs)
+	if len(up) == 0 {
+		return nil, errNoAddrAvailable
+	}
+
+	if len(up) == 1 {
+		return up[0], nil
+	}
+
+	p.mu.Lock()
+	i := p.rand.Intn(len(up))
+	j := p.rand.Intn(len(up) - 1)
+	p.mu.Unlock()
+
+	if j >= i {
+		j++
+	}
+
+	first, second := up[i], up[j]
+	if second.capacity() < first.capacity() {
+		return second, nil
+	}
+
+	return first, nil
+}
+
+// WeightedRoundRobinPicker distributes picks across up addresses in
+// proportion to each address's weight, read from the address's
+// grpc.Address.Metadata via WeightFunc. Addresses with a zero or negative
+// weight are treated as weight 1. It implements the classic smooth weighted
+// round-robin scheduling algorithm (as used by, e.g., nginx upstreams).
+type WeightedRoundRobinPicker struct {
+	// WeightFunc extracts the weight of an address from its Metadata. It
+	// defaults to treating Metadata as an int weight, falling back to 1 for
+	// any other type.
+	WeightFunc func(meta interface{}) int
+
+	mu      sync.Mutex
+	current map[*address]int
+}
+
+func (p *WeightedRoundRobinPicker) weightOf(a *address) int {
+	weightFunc := p.WeightFunc
+	if weightFunc == nil {
+		weightFunc = defaultWeightFunc
+	}
+
+	if w := weightFunc(a.Metadata); w > 0 {
+		return w
+	}
+
+	return 1
+}
+
+func defaultWeightFunc(meta interface{}) int {
+	w, ok := meta.(int)
+	if !ok {
+		return 1
+	}
+
+	return w
+}
+
+func (p *WeightedRoundRobinPicker) Pick(addrs []*address) (*address, error) {
+	up := upAddrs(addrs)
+	if len(up) == 0 {
+		return nil, errNoAddrAvailable
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pruneLocked(addrs)
+
+	var chosen *address
+	best := -1
+
+	for _, a := range up {
+		p.current[a] += p.weightOf(a)
+		if c := p.current[a]; chosen == nil || c > best {
+			chosen = a
+			best = c
+		}
+	}
+
+	p.current[chosen] -= p.totalWeight(up)
+
+	return chosen, nil
+}
+
+// pruneLocked drops p.current entries for addresses no longer present in
+// addrs. rebuildAddrs replaces dropped backends with fresh *address values
+// rather than reusing the old ones, so without this every resolver update
+// that drops a backend would leak its old *address into p.current forever.
+// p.mu must be held by the caller.
+func (p *WeightedRoundRobinPicker) pruneLocked(addrs []*address) {
+	if p.current == nil {
+		p.current = make(map[*address]int, len(addrs))
+		return
+	}
+
+	live := make(map[*address]struct{}, len(addrs))
+	for _, a := range addrs {
+		live[a] = struct{}{}
+	}
+
+	for a := range p.current {
+		if _, ok := live[a]; !ok {
+			delete(p.current, a)
+		}
+	}
+}
+
+func (p *WeightedRoundRobinPicker) totalWeight(addrs []*address) int {
+	total := 0
+	for _, a := range addrs {
+		total += p.weightOf(a)
+	}
+
+	return total
+}
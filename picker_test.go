@@ -0,0 +1,148 @@
+package throughputlb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func upAddr(addr string, active, max int) *address {
+	return &address{
+		Address:        grpc.Address{Addr: addr},
+		state:          stateUp,
+		activeRequests: active,
+		maxRequests:    max,
+	}
+}
+
+func TestLeastLoadedPickerSkipsFullAndDownAddresses(t *testing.T) {
+	full := upAddr("full", 2, 2)
+	down := &address{Address: grpc.Address{Addr: "down"}, state: stateDown, maxRequests: 2}
+	free := upAddr("free", 1, 2)
+
+	got, err := (LeastLoadedPicker{}).Pick([]*address{full, down, free})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	if got != free {
+		t.Fatalf("Pick = %v, want the only address with spare capacity", got.Addr)
+	}
+}
+
+func TestLeastLoadedPickerNoCapacity(t *testing.T) {
+	full := upAddr("full", 2, 2)
+
+	if _, err := (LeastLoadedPicker{}).Pick([]*address{full}); err != errNoAddrAvailable {
+		t.Fatalf("Pick = %v, want errNoAddrAvailable", err)
+	}
+}
+
+func TestRoundRobinPickerCycles(t *testing.T) {
+	addrs := []*address{upAddr("a", 0, 1), upAddr("b", 0, 1), upAddr("c", 0, 1)}
+	p := &RoundRobinPicker{}
+
+	seen := map[string]int{}
+	for i := 0; i < 9; i++ {
+		got, err := p.Pick(addrs)
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		seen[got.Addr]++
+	}
+
+	for _, a := range addrs {
+		if seen[a.Addr] != 3 {
+			t.Errorf("address %s picked %d times, want 3", a.Addr, seen[a.Addr])
+		}
+	}
+}
+
+func TestPickFirstPickerFallsBackWhenFull(t *testing.T) {
+	first := upAddr("first", 1, 1)
+	second := upAddr("second", 0, 1)
+
+	got, err := (PickFirstPicker{}).Pick([]*address{first, second})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	if got != second {
+		t.Fatalf("Pick = %v, want second (first is at capacity)", got.Addr)
+	}
+}
+
+// TestPowerOfTwoChoicesPickerPicksLowerLoadFromPairOfTwo exercises the
+// backlog's "must not bias toward the same index twice" requirement: with
+// exactly two up addresses, p.rand.Intn(len(up)-1) only ever has one
+// possible value, and the j>=i bump is what keeps the second sample from
+// landing on the same address as the first. If that logic regressed, the
+// lower-loaded address would stop winning every single call.
+func TestPowerOfTwoChoicesPickerPicksLowerLoadFromPairOfTwo(t *testing.T) {
+	low := upAddr("low", 1, 10)
+	high := upAddr("high", 5, 10)
+	addrs := []*address{low, high}
+
+	p := NewPowerOfTwoChoicesPicker()
+
+	for i := 0; i < 50; i++ {
+		got, err := p.Pick(addrs)
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		if got != low {
+			t.Fatalf("Pick = %v, want the lower-loaded address", got.Addr)
+		}
+	}
+}
+
+func TestPowerOfTwoChoicesPickerSingleUpAddress(t *testing.T) {
+	only := upAddr("only", 0, 1)
+
+	got, err := NewPowerOfTwoChoicesPicker().Pick([]*address{only})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	if got != only {
+		t.Fatalf("Pick = %v, want the only up address", got.Addr)
+	}
+}
+
+func TestWeightedRoundRobinPickerPrunesDroppedAddresses(t *testing.T) {
+	a := upAddr("a", 0, 1)
+	b := upAddr("b", 0, 1)
+
+	p := &WeightedRoundRobinPicker{}
+
+	if _, err := p.Pick([]*address{a, b}); err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	if len(p.current) != 2 {
+		t.Fatalf("len(current) = %d, want 2", len(p.current))
+	}
+
+	// Simulate rebuildAddrs dropping b for a resolved backend set that no
+	// longer includes it.
+	if _, err := p.Pick([]*address{a}); err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+
+	if _, ok := p.current[b]; ok {
+		t.Fatal("current still holds an entry for an address no longer in the live set")
+	}
+}
+
+// TestNextDoesNotReturnSuccessWhenAddressIsFull guards against claim's
+// error return being silently discarded: next must never hand back an
+// address that's already at maxRequests.
+func TestNextDoesNotReturnSuccessWhenAddressIsFull(t *testing.T) {
+	lb := NewThroughputLoadBalancer(1, 1)
+	lb.addrs = []*address{upAddr("full", 1, 1)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	if _, err := lb.next(ctx, true); err == nil {
+		t.Fatal("next returned success for an address already at maxRequests")
+	}
+}
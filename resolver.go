@@ -0,0 +1,253 @@
+package throughputlb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver discovers the set of backend addresses a target name refers to
+// and watches for changes, letting ThroughputLoadBalancer spread its
+// numAddrs subconns across a replica set instead of opening them all to a
+// single host.
+type Resolver interface {
+	// Resolve returns the current set of backend addresses for target.
+	Resolve(ctx context.Context, target string) ([]string, error)
+
+	// Watch returns a channel of backend address sets, pushed whenever the
+	// resolved set changes. It is closed when ctx is done, or right away by
+	// a Resolver whose set never changes.
+	Watch(ctx context.Context) (<-chan []string, error)
+}
+
+// staticResolver resolves a fixed, comma-separated list of addresses, e.g.
+// "static:///a:1,b:2", and never changes.
+type staticResolver struct{}
+
+// NewStaticResolver returns a Resolver for targets of the form
+// "static:///addr1,addr2,...".
+func NewStaticResolver() Resolver {
+	return staticResolver{}
+}
+
+func (staticResolver) Resolve(_ context.Context, target string) ([]string, error) {
+	target = strings.TrimPrefix(target, "static:///")
+
+	var addrs []string
+	for _, a := range strings.Split(target, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("throughputlb: no addresses in static target %q", target)
+	}
+
+	return addrs, nil
+}
+
+func (r staticResolver) Watch(_ context.Context) (<-chan []string, error) {
+	ch := make(chan []string)
+	close(ch)
+
+	return ch, nil
+}
+
+// dnsResolver resolves a "dns:///host:port" target by periodically doing a
+// net.LookupHost on host and pairing every returned IP with port.
+type dnsResolver struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	target string
+}
+
+// NewDNSResolver returns a Resolver for targets of the form
+// "dns:///host:port" that re-resolves host every ttl.
+func NewDNSResolver(ttl time.Duration) Resolver {
+	return &dnsResolver{ttl: ttl}
+}
+
+func (r *dnsResolver) hostPort() (string, string, error) {
+	r.mu.Lock()
+	target := r.target
+	r.mu.Unlock()
+
+	return net.SplitHostPort(strings.TrimPrefix(target, "dns:///"))
+}
+
+func (r *dnsResolver) Resolve(ctx context.Context, target string) ([]string, error) {
+	r.mu.Lock()
+	r.target = target
+	r.mu.Unlock()
+
+	return r.resolve(ctx)
+}
+
+func (r *dnsResolver) resolve(ctx context.Context) ([]string, error) {
+	host, port, err := r.hostPort()
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, port)
+	}
+
+	sort.Strings(addrs)
+
+	return addrs, nil
+}
+
+func (r *dnsResolver) Watch(ctx context.Context) (<-chan []string, error) {
+	ch := make(chan []string)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(r.ttl)
+		defer ticker.Stop()
+
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		attempt := 0
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			addrs, err := r.resolve(ctx)
+			if err != nil {
+				if !backoffWait(ctx, DefaultBackoffConfig, rnd, attempt) {
+					return
+				}
+
+				attempt++
+				continue
+			}
+			attempt = 0
+
+			select {
+			case ch <- addrs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// srvResolver resolves a "srv:///_service._proto.name" target via SRV
+// records, ordering addresses by priority then weight as net.LookupSRV
+// already does.
+type srvResolver struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	name string
+}
+
+// NewSRVResolver returns a Resolver for targets of the form
+// "srv:///_service._proto.name" that re-resolves every ttl.
+func NewSRVResolver(ttl time.Duration) Resolver {
+	return &srvResolver{ttl: ttl}
+}
+
+func (r *srvResolver) Resolve(ctx context.Context, target string) ([]string, error) {
+	r.mu.Lock()
+	r.name = strings.TrimPrefix(target, "srv:///")
+	r.mu.Unlock()
+
+	return r.resolve(ctx)
+}
+
+func (r *srvResolver) resolve(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	name := r.name
+	r.mu.Unlock()
+
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(srvs))
+	for i, srv := range srvs {
+		addrs[i] = net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+	}
+
+	return addrs, nil
+}
+
+func (r *srvResolver) Watch(ctx context.Context) (<-chan []string, error) {
+	ch := make(chan []string)
+
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(r.ttl)
+		defer ticker.Stop()
+
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		attempt := 0
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			addrs, err := r.resolve(ctx)
+			if err != nil {
+				if !backoffWait(ctx, DefaultBackoffConfig, rnd, attempt) {
+					return
+				}
+
+				attempt++
+				continue
+			}
+			attempt = 0
+
+			select {
+			case ch <- addrs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// distributeBackends assigns n subconns across backends round-robin, e.g.
+// distributeBackends(5, []string{"a","b"}) ==
+// []string{"a","b","a","b","a"}.
+func distributeBackends(n int, backends []string) []string {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	assigned := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		assigned = append(assigned, backends[i%len(backends)])
+	}
+
+	return assigned
+}
@@ -0,0 +1,79 @@
+package throughputlb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForInFlightRequestsToDrain(t *testing.T) {
+	lb := NewThroughputLoadBalancer(2, 1)
+	addr := upAddr("a", 0, 2)
+	lb.addrs = []*address{addr}
+
+	if err := addr.claim(); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- lb.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned (err=%v) before the in-flight request released", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	addr.release()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Shutdown returned error after drain completed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return once the in-flight request released")
+	}
+}
+
+func TestClaimRejectsAfterShutdown(t *testing.T) {
+	lb := NewThroughputLoadBalancer(2, 1)
+	addr := upAddr("a", 0, 2)
+	lb.addrs = []*address{addr}
+
+	if err := lb.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if err := addr.claim(); err != errAddressClosed {
+		t.Fatalf("claim after Shutdown = %v, want errAddressClosed", err)
+	}
+}
+
+func TestShutdownIsIdempotent(t *testing.T) {
+	lb := NewThroughputLoadBalancer(2, 1)
+
+	err1 := lb.Shutdown(context.Background())
+	err2 := lb.Shutdown(context.Background())
+
+	if err1 != err2 {
+		t.Fatalf("Shutdown results differ across calls: %v vs %v", err1, err2)
+	}
+}
+
+func TestShutdownHonorsCallerDeadline(t *testing.T) {
+	lb := NewThroughputLoadBalancer(2, 1)
+	addr := upAddr("a", 0, 2)
+	lb.addrs = []*address{addr}
+
+	if err := addr.claim(); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	if err := lb.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,196 @@
+package throughputlb
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSink receives live updates as the balancer claims/releases
+// addresses and observes transport state changes. WithMetricsSink and
+// WithPrometheusRegisterer both configure one; Stats is always available
+// regardless of whether a sink is configured.
+type MetricsSink interface {
+	// SetActiveRequests reports the current in-flight request count for
+	// addr/idx, called from claim and release.
+	SetActiveRequests(addr string, idx int, active int)
+
+	// IncRejections is called from claim when addr/idx is at capacity.
+	IncRejections(addr string, idx int)
+
+	// SetUp is called from goUp/goDown as addr/idx's transport state
+	// changes.
+	SetUp(addr string, idx int, up bool)
+}
+
+// AddressStats is the point-in-time snapshot of one address's bookkeeping.
+type AddressStats struct {
+	Addr              string
+	Idx               int
+	Up                bool
+	Picks             uint64
+	Rejections        uint64
+	CurrentActive     int
+	MaxActiveObserved int
+	UpSince           time.Time
+	DownSince         time.Time
+	LastError         error
+}
+
+// Snapshot is the point-in-time bookkeeping returned by Stats.
+type Snapshot struct {
+	Addresses           []AddressStats
+	TotalWaitDuration   time.Duration
+	WaitSamples         uint64
+	UnavailableReturned uint64
+}
+
+// Stats returns a snapshot of the balancer's bookkeeping: per-address pick,
+// rejection and active-request counts, and LB-wide wait/unavailable
+// counters.
+func (lb *ThroughputLoadBalancer) Stats() Snapshot {
+	lb.mu.RLock()
+	addrs := lb.addrs
+	lb.mu.RUnlock()
+
+	snap := Snapshot{Addresses: make([]AddressStats, len(addrs))}
+	for i, a := range addrs {
+		snap.Addresses[i] = a.stats()
+	}
+
+	lb.statsMu.Lock()
+	snap.TotalWaitDuration = lb.totalWaitDuration
+	snap.WaitSamples = lb.waitSamples
+	snap.UnavailableReturned = lb.unavailableReturned
+	lb.statsMu.Unlock()
+
+	return snap
+}
+
+func (a *address) stats() AddressStats {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return AddressStats{
+		Addr:              a.Addr,
+		Idx:               a.idx,
+		Up:                a.state == stateUp && !a.unhealthy,
+		Picks:             a.picks,
+		Rejections:        a.rejections,
+		CurrentActive:     a.activeRequests,
+		MaxActiveObserved: a.maxActiveObserved,
+		UpSince:           a.upSince,
+		DownSince:         a.downSince,
+		LastError:         a.lastErr,
+	}
+}
+
+func (lb *ThroughputLoadBalancer) recordWait(d time.Duration) {
+	lb.statsMu.Lock()
+	defer lb.statsMu.Unlock()
+
+	lb.totalWaitDuration += d
+	lb.waitSamples++
+}
+
+func (lb *ThroughputLoadBalancer) recordUnavailable() {
+	lb.statsMu.Lock()
+	defer lb.statsMu.Unlock()
+
+	lb.unavailableReturned++
+}
+
+// WithPrometheusRegisterer registers throughputlb_address_active_requests,
+// throughputlb_address_rejections_total and throughputlb_address_up gauges/
+// counters (labeled by addr and idx) with reg and forwards claim/release/
+// goUp/goDown events to them.
+func WithPrometheusRegisterer(reg prometheus.Registerer) ThroughputLoadBalancerOption {
+	return WithMetricsSink(newPrometheusSink(reg))
+}
+
+type prometheusSink struct {
+	activeRequests *prometheus.GaugeVec
+	rejections     *prometheus.CounterVec
+	up             *prometheus.GaugeVec
+}
+
+func newPrometheusSink(reg prometheus.Registerer) *prometheusSink {
+	activeRequests := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "throughputlb_address_active_requests",
+		Help: "Current number of in-flight requests claimed against an address.",
+	}, []string{"addr", "idx"})
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "throughputlb_address_rejections_total",
+		Help: "Total number of claims rejected because an address was at capacity.",
+	}, []string{"addr", "idx"})
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "throughputlb_address_up",
+		Help: "1 if an address's transport is up, 0 otherwise.",
+	}, []string{"addr", "idx"})
+
+	return &prometheusSink{
+		activeRequests: registerGaugeVec(reg, activeRequests),
+		rejections:     registerCounterVec(reg, rejections),
+		up:             registerGaugeVec(reg, up),
+	}
+}
+
+// registerGaugeVec registers gv with reg, returning the already-registered
+// GaugeVec in its place if an equivalent collector was already registered
+// (e.g. a second ThroughputLoadBalancer sharing reg) instead of panicking
+// like MustRegister would.
+func registerGaugeVec(reg prometheus.Registerer, gv *prometheus.GaugeVec) *prometheus.GaugeVec {
+	err := reg.Register(gv)
+	if err == nil {
+		return gv
+	}
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(*prometheus.GaugeVec); ok {
+			return existing
+		}
+	}
+
+	return gv
+}
+
+// registerCounterVec is registerGaugeVec for *prometheus.CounterVec.
+func registerCounterVec(reg prometheus.Registerer, cv *prometheus.CounterVec) *prometheus.CounterVec {
+	err := reg.Register(cv)
+	if err == nil {
+		return cv
+	}
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(*prometheus.CounterVec); ok {
+			return existing
+		}
+	}
+
+	return cv
+}
+
+func idxLabel(idx int) string {
+	return strconv.Itoa(idx)
+}
+
+func (s *prometheusSink) SetActiveRequests(addr string, idx int, active int) {
+	s.activeRequests.WithLabelValues(addr, idxLabel(idx)).Set(float64(active))
+}
+
+func (s *prometheusSink) IncRejections(addr string, idx int) {
+	s.rejections.WithLabelValues(addr, idxLabel(idx)).Inc()
+}
+
+func (s *prometheusSink) SetUp(addr string, idx int, up bool) {
+	v := 0.0
+	if up {
+		v = 1.0
+	}
+
+	s.up.WithLabelValues(addr, idxLabel(idx)).Set(v)
+}
@@ -1,7 +1,14 @@
+// Package throughputlb implements ThroughputLoadBalancer against the
+// deprecated grpc.Balancer v1 interface (Start/Up/Get/Notify/Close). It is
+// kept as a compatibility shim for callers still dialing with
+// grpc.WithBalancer; new integrations should use the v2 subpackage, which
+// implements the same least-active-requests selection against the current
+// balancer.Builder/balancer.Picker surface.
 package throughputlb
 
 import (
 	"errors"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -13,6 +20,7 @@ import (
 var (
 	errUnavailable         = grpc.Errorf(codes.Unavailable, "there is no address available")
 	errMaxRequestsExceeded = errors.New("max requests exceeded")
+	errAddressClosed       = errors.New("address closed")
 )
 
 type addrState int64
@@ -20,6 +28,13 @@ type addrState int64
 const (
 	stateDown addrState = iota
 	stateUp
+
+	// stateClosed marks an address torn down by Close/Shutdown. It is
+	// distinct from stateDown so a goUp/goDown racing with shutdown can't
+	// resurrect an address that's being drained, and so claim can reject
+	// new requests against it with a dedicated error instead of the
+	// capacity one.
+	stateClosed
 )
 
 type address struct {
@@ -29,17 +44,63 @@ type address struct {
 	state          addrState
 	activeRequests int
 	maxRequests    int
+
+	// unhealthy and healthCancel track application-level health, as
+	// reported by an optional health checker, separately from transport
+	// connectivity. An address must be both transport-up and healthy to be
+	// picked.
+	unhealthy    bool
+	healthCancel context.CancelFunc
+
+	// idx and sink are set by rebuildAddrs and let claim/release/goUp/goDown
+	// forward to an optional MetricsSink. The remaining fields back Stats.
+	idx               int
+	sink              MetricsSink
+	picks             uint64
+	rejections        uint64
+	maxActiveObserved int
+	upSince           time.Time
+	downSince         time.Time
+	lastErr           error
+}
+
+// configure sets the index and metrics sink an address reports through.
+// Called by rebuildAddrs; idx may change across rebuilds even when the
+// address itself is reused.
+func (a *address) configure(idx int, sink MetricsSink) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.idx = idx
+	a.sink = sink
 }
 
 func (a *address) claim() error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.state == stateClosed {
+		return errAddressClosed
+	}
+
 	if a.activeRequests >= a.maxRequests {
+		a.rejections++
+		if a.sink != nil {
+			a.sink.IncRejections(a.Addr, a.idx)
+		}
+
 		return errMaxRequestsExceeded
 	}
 
 	a.activeRequests++
+	a.picks++
+	if a.activeRequests > a.maxActiveObserved {
+		a.maxActiveObserved = a.activeRequests
+	}
+
+	if a.sink != nil {
+		a.sink.SetActiveRequests(a.Addr, a.idx, a.activeRequests)
+	}
 
 	return nil
 }
@@ -49,36 +110,99 @@ func (a *address) release() {
 	defer a.mu.Unlock()
 
 	a.activeRequests--
+
+	if a.sink != nil {
+		a.sink.SetActiveRequests(a.Addr, a.idx, a.activeRequests)
+	}
 }
 
 func (a *address) goUp() {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.state == stateClosed {
+		return
+	}
+
 	a.state = stateUp
+	a.upSince = time.Now()
+
+	if a.sink != nil {
+		a.sink.SetUp(a.Addr, a.idx, true)
+	}
 }
 
-func (a *address) goDown(_ error) {
+func (a *address) goDown(err error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// TODO: Handle error
+	if a.state == stateClosed {
+		return
+	}
 
 	a.state = stateDown
+	a.unhealthy = false
+	a.downSince = time.Now()
+	a.lastErr = err
+
+	if a.healthCancel != nil {
+		a.healthCancel()
+		a.healthCancel = nil
+	}
+
+	if a.sink != nil {
+		a.sink.SetUp(a.Addr, a.idx, false)
+	}
+}
+
+// markClosed tears a.state down permanently for Shutdown: it takes
+// precedence over any later goUp/goDown so a racing transport callback
+// can't resurrect an address mid-drain, but it leaves activeRequests alone
+// so in-flight release calls still land on a consistent count instead of
+// underflowing a count Shutdown reset out from under them.
+func (a *address) markClosed() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.state = stateClosed
+	a.downSince = time.Now()
+
+	if a.healthCancel != nil {
+		a.healthCancel()
+		a.healthCancel = nil
+	}
+
+	if a.sink != nil {
+		a.sink.SetUp(a.Addr, a.idx, false)
+	}
 }
 
 func (a *address) isUp() bool {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	return a.state == stateUp
+	return a.state == stateUp && !a.unhealthy
 }
 
-func (a *address) isDown() bool {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
+func (a *address) setHealthCancel(cancel context.CancelFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.healthCancel = cancel
+}
+
+func (a *address) markHealthy() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.unhealthy = false
+}
 
-	return a.state == stateDown
+func (a *address) markUnhealthy() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.unhealthy = true
 }
 
 func (a *address) capacity() int {
@@ -88,6 +212,16 @@ func (a *address) capacity() int {
 	return a.activeRequests
 }
 
+// hasCapacity reports whether a still has room for another claim. Pickers
+// use it alongside isUp so next doesn't keep choosing an address that's up
+// but already at maxRequests.
+func (a *address) hasCapacity() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.activeRequests < a.maxRequests
+}
+
 type ThroughputLoadBalancerOption func(*ThroughputLoadBalancer)
 
 func WithCleanupInterval(d time.Duration) ThroughputLoadBalancerOption {
@@ -96,6 +230,58 @@ func WithCleanupInterval(d time.Duration) ThroughputLoadBalancerOption {
 	}
 }
 
+// WithBackoff overrides the default exponential backoff used by Get while it
+// waits for an address to free up capacity.
+func WithBackoff(cfg BackoffConfig) ThroughputLoadBalancerOption {
+	return func(lb *ThroughputLoadBalancer) {
+		lb.backoff = cfg
+	}
+}
+
+// WithPicker overrides the default LeastLoadedPicker strategy used to select
+// an address in next.
+func WithPicker(p Picker) ThroughputLoadBalancerOption {
+	return func(lb *ThroughputLoadBalancer) {
+		lb.picker = p
+	}
+}
+
+// WithResolver makes Start resolve its target through r instead of treating
+// it as a single backend, spreading numAddrs subconns across the resolved
+// backend set and re-resolving as r reports changes.
+func WithResolver(r Resolver) ThroughputLoadBalancerOption {
+	return func(lb *ThroughputLoadBalancer) {
+		lb.resolver = r
+	}
+}
+
+// WithHealthCheck makes the balancer watch the grpc.health.v1.Health service
+// on every address whose transport comes up, marking it unhealthy (and so
+// ineligible for picking) when it reports anything other than SERVING.
+func WithHealthCheck(service string, interval time.Duration) ThroughputLoadBalancerOption {
+	return func(lb *ThroughputLoadBalancer) {
+		lb.healthCheck = &healthCheckConfig{service: service, interval: interval}
+	}
+}
+
+// WithMetricsSink forwards per-address and per-pick events to sink as they
+// happen, in addition to the bookkeeping Stats always does.
+func WithMetricsSink(sink MetricsSink) ThroughputLoadBalancerOption {
+	return func(lb *ThroughputLoadBalancer) {
+		lb.metricsSink = sink
+	}
+}
+
+// WithDrainTimeout bounds how long Close waits for in-flight requests to
+// finish against every address before giving up and closing anyway.
+// Shutdown honors the caller's own context instead, so this only affects
+// Close, which has no context of its own to bound on.
+func WithDrainTimeout(d time.Duration) ThroughputLoadBalancerOption {
+	return func(lb *ThroughputLoadBalancer) {
+		lb.drainTimeout = d
+	}
+}
+
 type ThroughputLoadBalancer struct {
 	mu    sync.RWMutex
 	addrs []*address
@@ -105,6 +291,28 @@ type ThroughputLoadBalancer struct {
 	maxRequests     int
 	numAddrs        int
 	cleanupInterval time.Duration
+	backoff         BackoffConfig
+	rand            *rand.Rand
+	picker          Picker
+	resolver        Resolver
+	healthCheck     *healthCheckConfig
+	metricsSink     MetricsSink
+	drainTimeout    time.Duration
+
+	statsMu             sync.Mutex
+	totalWaitDuration   time.Duration
+	waitSamples         uint64
+	unavailableReturned uint64
+
+	closeOnce sync.Once
+	closeErr  error
+	closed    bool // guarded by mu; set before draining so a racing rebuildAddrs bails out
+
+	notifyMu     sync.Mutex
+	notifyClosed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewThroughputLoadBalancer(
@@ -112,12 +320,19 @@ func NewThroughputLoadBalancer(
 	numAddrs int,
 	opts ...ThroughputLoadBalancerOption,
 ) *ThroughputLoadBalancer {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	lb := &ThroughputLoadBalancer{
 		notify:          make(chan []grpc.Address, numAddrs),
-		addrs:           make([]*address, numAddrs, numAddrs),
 		maxRequests:     maxRequests,
 		numAddrs:        numAddrs,
 		cleanupInterval: time.Minute,
+		drainTimeout:    30 * time.Second,
+		backoff:         DefaultBackoffConfig,
+		rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		picker:          LeastLoadedPicker{},
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 
 	for _, o := range opts {
@@ -130,22 +345,96 @@ func NewThroughputLoadBalancer(
 func (lb *ThroughputLoadBalancer) Start(target string, cfg grpc.BalancerConfig) error {
 	// TODO: Validate target and return error if invalid
 
-	lb.mu.Lock()
 	lb.target = target
-	for i := 0; i < lb.numAddrs; i++ {
-		lb.addrs[i] = &address{
-			Address: grpc.Address{
-				Addr:     lb.target,
-				Metadata: i,
-			},
+
+	if lb.resolver == nil {
+		lb.rebuildAddrs([]string{target})
+
+		return nil
+	}
+
+	backends, err := lb.resolver.Resolve(lb.ctx, target)
+	if err != nil {
+		return err
+	}
+
+	lb.rebuildAddrs(backends)
+
+	go lb.watchResolver()
+
+	return nil
+}
+
+// watchResolver consumes backend set updates from lb.resolver until lb.ctx
+// is cancelled, rebuilding lb.addrs and re-notifying on every change.
+func (lb *ThroughputLoadBalancer) watchResolver() {
+	updates, err := lb.resolver.Watch(lb.ctx)
+	if err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-lb.ctx.Done():
+			return
+		case backends, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			lb.rebuildAddrs(backends)
+		}
+	}
+}
+
+// rebuildAddrs recomputes lb.addrs for the given backend set, spreading
+// lb.numAddrs subconns across it. Addresses for backends that are still
+// present are reused as-is so their in-flight request counts and up/down
+// state survive the rebuild; addresses for backends no longer present are
+// simply dropped from lb.addrs. Any request already in flight against a
+// dropped address keeps the *address it was handed and still calls release
+// on it, so it drains naturally without needing active teardown here.
+//
+// rebuildAddrs is a no-op once the balancer is closed: otherwise a resolver
+// update racing with Shutdown could install fresh addresses that were never
+// passed through markClosed and never counted by its drain, leaving traffic
+// flowing after Shutdown has already reported success.
+func (lb *ThroughputLoadBalancer) rebuildAddrs(backends []string) {
+	assigned := distributeBackends(lb.numAddrs, backends)
+
+	lb.mu.Lock()
+
+	if lb.closed {
+		lb.mu.Unlock()
+		return
+	}
+
+	available := make(map[string][]*address, len(lb.addrs))
+	for _, a := range lb.addrs {
+		available[a.Addr] = append(available[a.Addr], a)
+	}
+
+	newAddrs := make([]*address, len(assigned))
+	for i, backend := range assigned {
+		if reuse := available[backend]; len(reuse) > 0 {
+			newAddrs[i], available[backend] = reuse[0], reuse[1:]
+			continue
+		}
+
+		newAddrs[i] = &address{
+			Address:     grpc.Address{Addr: backend, Metadata: i},
 			maxRequests: lb.maxRequests,
 		}
 	}
+
+	for i, a := range newAddrs {
+		a.configure(i, lb.metricsSink)
+	}
+
+	lb.addrs = newAddrs
 	lb.mu.Unlock()
 
 	lb.sendNotify()
-
-	return nil
 }
 
 func (lb *ThroughputLoadBalancer) Up(addr grpc.Address) func(error) {
@@ -157,6 +446,13 @@ func (lb *ThroughputLoadBalancer) Up(addr grpc.Address) func(error) {
 		if a.Address == addr {
 			a.goUp()
 
+			if lb.healthCheck != nil {
+				ctx, cancel := context.WithCancel(lb.ctx)
+				a.setHealthCancel(cancel)
+
+				go lb.healthCheck.watch(ctx, a)
+			}
+
 			return a.goDown
 		}
 	}
@@ -165,7 +461,7 @@ func (lb *ThroughputLoadBalancer) Up(addr grpc.Address) func(error) {
 }
 
 func (lb *ThroughputLoadBalancer) Get(ctx context.Context, opts grpc.BalancerGetOptions) (grpc.Address, func(), error) {
-	addr, err := lb.next(opts.BlockingWait)
+	addr, err := lb.next(ctx, opts.BlockingWait)
 	if err != nil {
 		return grpc.Address{}, func() {}, err
 	}
@@ -177,12 +473,90 @@ func (lb *ThroughputLoadBalancer) Notify() <-chan []grpc.Address {
 	return lb.notify
 }
 
-func (*ThroughputLoadBalancer) Close() error {
-	// TODO: Should this remove all addresses and notify or just stop opperation?
+// Close implements grpc.Balancer by shutting down with a context bounded by
+// lb.drainTimeout. It always returns nil, matching the grpc.Balancer
+// interface; use Shutdown directly to observe whether the drain completed
+// or timed out.
+func (lb *ThroughputLoadBalancer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lb.drainTimeout)
+	defer cancel()
+
+	lb.Shutdown(ctx)
 
 	return nil
 }
 
+// Shutdown marks every address closed so new Get calls stop being routed to
+// them (wait=true callers keep retrying until their own ctx is done and then
+// get a Canceled/DeadlineExceeded status; wait=false callers get
+// errUnavailable at once), cancels the background resolver-watch and
+// health-check goroutines, and waits for every address's in-flight
+// activeRequests to drain to zero before closing the notify channel. It
+// honors ctx's own deadline/cancellation rather than any WithDrainTimeout,
+// and is safe to call more than once or concurrently with Close; only the
+// first call does any work, and its result is returned to every caller.
+func (lb *ThroughputLoadBalancer) Shutdown(ctx context.Context) error {
+	lb.closeOnce.Do(func() {
+		lb.mu.Lock()
+		lb.closed = true
+		addrs := lb.addrs
+		lb.mu.Unlock()
+
+		for _, a := range addrs {
+			a.markClosed()
+		}
+
+		lb.cancel()
+
+		lb.closeErr = drain(ctx, addrs)
+
+		lb.notifyMu.Lock()
+		lb.notifyClosed = true
+		close(lb.notify)
+		lb.notifyMu.Unlock()
+	})
+
+	return lb.closeErr
+}
+
+// drainPollInterval is how often drain re-checks whether every address has
+// reached zero active requests.
+const drainPollInterval = 10 * time.Millisecond
+
+// drain blocks until every address in addrs reports zero active requests or
+// ctx is done, whichever comes first.
+func drain(ctx context.Context, addrs []*address) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if allDrained(addrs) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func allDrained(addrs []*address) bool {
+	for _, a := range addrs {
+		if a.capacity() > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sendNotify publishes the current address set on lb.notify. It is called
+// with lb.closed already false (rebuildAddrs checked under lb.mu before
+// calling it), but Shutdown can still close lb.notify concurrently, so the
+// send itself is guarded by notifyMu, the same lock Shutdown takes before
+// closing the channel.
 func (lb *ThroughputLoadBalancer) sendNotify() {
 	lb.mu.RLock()
 	addrs := lb.addrs
@@ -193,36 +567,62 @@ func (lb *ThroughputLoadBalancer) sendNotify() {
 		grpcAddrs[i] = a.Address
 	}
 
+	lb.notifyMu.Lock()
+	defer lb.notifyMu.Unlock()
+
+	if lb.notifyClosed {
+		return
+	}
+
 	lb.notify <- grpcAddrs
 }
 
-func (lb *ThroughputLoadBalancer) next(wait bool) (*address, error) {
-	for {
-		var addr *address
-		lowestCapacity := lb.maxRequests * 2
+func (lb *ThroughputLoadBalancer) next(ctx context.Context, wait bool) (*address, error) {
+	start := time.Now()
 
+	for attempt := 0; ; attempt++ {
 		lb.mu.RLock()
-		for _, a := range lb.addrs {
-			if a.isDown() {
-				continue
-			}
+		addr, _ := lb.picker.Pick(lb.addrs)
+		lb.mu.RUnlock()
 
-			if a.capacity() < lowestCapacity {
-				addr = a
-				lowestCapacity = a.capacity()
+		if addr != nil && addr.claim() == nil {
+			if attempt > 0 {
+				lb.recordWait(time.Since(start))
 			}
-		}
-		lb.mu.RUnlock()
 
-		if addr != nil {
-			addr.claim()
 			return addr, nil
 		}
 
 		if !wait {
+			lb.recordUnavailable()
 			return nil, errUnavailable
 		}
 
-		time.Sleep(50 * time.Millisecond)
+		timer := time.NewTimer(lb.backoffDelay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			lb.recordWait(time.Since(start))
+			return nil, ctxErr(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay computes the jittered exponential backoff for the given
+// attempt (0-indexed), per lb.backoff.
+func (lb *ThroughputLoadBalancer) backoffDelay(attempt int) time.Duration {
+	return jitteredDelay(lb.backoff, lb.rand, attempt)
+}
+
+// ctxErr translates a cancelled/expired context into the gRPC status error
+// Get should surface, matching the semantics gRPC's own wait-for-ready
+// picker loop uses.
+func ctxErr(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return grpc.Errorf(codes.DeadlineExceeded, "%v", ctx.Err())
+	default:
+		return grpc.Errorf(codes.Canceled, "%v", ctx.Err())
 	}
 }
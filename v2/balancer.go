@@ -0,0 +1,162 @@
+// Package v2 is a throughputlb.ThroughputLoadBalancer implementation for the
+// modern gRPC balancer surface (balancer.Builder/balancer.Balancer/
+// balancer.Picker + resolver.Builder), replacing the deprecated
+// grpc.Balancer v1 API used by the top-level throughputlb package.
+package v2
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+// scheme is the custom resolver scheme used to reach this balancer, e.g.
+// "throughput:///my-target?n=32".
+const scheme = "throughput"
+
+func init() {
+	balancer.Register(newBuilder())
+}
+
+// Name is the balancer name registered with gRPC and referenced via
+// grpc.WithBalancerName or the service config "loadBalancingPolicy" field.
+const Name = "throughputlb"
+
+type builder struct{}
+
+func newBuilder() balancer.Builder {
+	return &builder{}
+}
+
+func (*builder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	b := &Balancer{
+		cc:       cc,
+		subConns: make(map[balancer.SubConn]*subConnInfo),
+		picker:   newErrPicker(balancer.ErrNoSubConnAvailable),
+	}
+
+	return b
+}
+
+func (*builder) Name() string {
+	return Name
+}
+
+type subConnState int
+
+const (
+	subConnStateConnecting subConnState = iota
+	subConnStateReady
+	subConnStateDown
+)
+
+type subConnInfo struct {
+	mu             sync.Mutex
+	state          subConnState
+	activeRequests int
+}
+
+// Balancer is a balancer.Balancer that creates numAddrs SubConns to the
+// resolved address and picks among the ones reporting Ready using a
+// least-active-requests strategy.
+type Balancer struct {
+	cc balancer.ClientConn
+
+	mu       sync.Mutex
+	subConns map[balancer.SubConn]*subConnInfo
+	picker   balancer.Picker
+}
+
+// UpdateClientConnState is called by gRPC whenever the resolver produces a
+// new set of addresses. It (re)creates the configured number of SubConns to
+// the first resolved address.
+func (b *Balancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	numAddrs := numAddrsFromTarget(s.ResolverState)
+	if numAddrs <= 0 {
+		numAddrs = 1
+	}
+
+	addrs := s.ResolverState.Addresses
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// SubConns from an earlier update are removed; gRPC then reports each as
+	// Shutdown through UpdateSubConnState, which drops it from b.subConns.
+	for sc := range b.subConns {
+		b.cc.RemoveSubConn(sc)
+	}
+
+	for i := 0; i < numAddrs; i++ {
+		sc, err := b.cc.NewSubConn(addrs, balancer.NewSubConnOptions{})
+		if err != nil {
+			continue
+		}
+
+		b.subConns[sc] = &subConnInfo{state: subConnStateConnecting}
+		sc.Connect()
+	}
+
+	return nil
+}
+
+// ResolverError is called by gRPC when the resolver reports an error. There
+// is nothing address-specific to react to here; existing SubConns keep
+// serving traffic until they themselves go down.
+func (b *Balancer) ResolverError(error) {}
+
+// UpdateSubConnState is called by gRPC whenever a SubConn's connectivity
+// state changes.
+func (b *Balancer) UpdateSubConnState(sc balancer.SubConn, s balancer.SubConnState) {
+	b.mu.Lock()
+	info, ok := b.subConns[sc]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+
+	switch s.ConnectivityState {
+	case connectivity.Ready:
+		info.mu.Lock()
+		info.state = subConnStateReady
+		info.mu.Unlock()
+	case connectivity.Shutdown:
+		delete(b.subConns, sc)
+	default:
+		info.mu.Lock()
+		info.state = subConnStateDown
+		info.mu.Unlock()
+	}
+
+	b.regeneratePickerLocked()
+	b.mu.Unlock()
+}
+
+// Close tears down the balancer. SubConns are closed by gRPC itself.
+func (b *Balancer) Close() {}
+
+// regeneratePickerLocked rebuilds the picker from the current set of ready
+// SubConns. b.mu must be held.
+func (b *Balancer) regeneratePickerLocked() {
+	ready := make([]*weightedSubConn, 0, len(b.subConns))
+	for sc, info := range b.subConns {
+		info.mu.Lock()
+		if info.state == subConnStateReady {
+			ready = append(ready, &weightedSubConn{sc: sc, info: info})
+		}
+		info.mu.Unlock()
+	}
+
+	if len(ready) == 0 {
+		b.picker = newErrPicker(balancer.ErrNoSubConnAvailable)
+		b.cc.UpdateState(balancer.State{ConnectivityState: connectivity.TransientFailure, Picker: b.picker})
+		return
+	}
+
+	b.picker = newLeastActivePicker(ready)
+	b.cc.UpdateState(balancer.State{ConnectivityState: connectivity.Ready, Picker: b.picker})
+}
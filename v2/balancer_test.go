@@ -0,0 +1,74 @@
+package v2
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+// fakeSubConn is a distinct pointer identity usable as a balancer.SubConn map
+// key; none of its methods are exercised by Balancer itself.
+type fakeSubConn struct {
+	balancer.SubConn
+}
+
+// fakeClientConn records the last state UpdateSubConnState pushed, so tests
+// can inspect the regenerated picker without a real gRPC ClientConn.
+type fakeClientConn struct {
+	balancer.ClientConn
+	lastState balancer.State
+}
+
+func (f *fakeClientConn) UpdateState(s balancer.State) {
+	f.lastState = s
+}
+
+func newTestBalancer() (*Balancer, *fakeClientConn) {
+	cc := &fakeClientConn{}
+	return &Balancer{
+		cc:       cc,
+		subConns: make(map[balancer.SubConn]*subConnInfo),
+		picker:   newErrPicker(balancer.ErrNoSubConnAvailable),
+	}, cc
+}
+
+func TestUpdateSubConnStateRegeneratesPickerOnReady(t *testing.T) {
+	b, cc := newTestBalancer()
+	sc := &fakeSubConn{}
+	b.subConns[sc] = &subConnInfo{state: subConnStateConnecting}
+
+	b.UpdateSubConnState(sc, balancer.SubConnState{ConnectivityState: connectivity.Ready})
+
+	if cc.lastState.ConnectivityState != connectivity.Ready {
+		t.Fatalf("ConnectivityState = %v, want Ready", cc.lastState.ConnectivityState)
+	}
+	if _, err := cc.lastState.Picker.Pick(balancer.PickInfo{}); err != nil {
+		t.Fatalf("Pick after Ready transition: %v", err)
+	}
+}
+
+func TestUpdateSubConnStateRegeneratesPickerOnTransientFailure(t *testing.T) {
+	b, cc := newTestBalancer()
+	sc := &fakeSubConn{}
+	b.subConns[sc] = &subConnInfo{state: subConnStateReady}
+
+	b.UpdateSubConnState(sc, balancer.SubConnState{ConnectivityState: connectivity.TransientFailure})
+
+	if cc.lastState.ConnectivityState != connectivity.TransientFailure {
+		t.Fatalf("ConnectivityState = %v, want TransientFailure", cc.lastState.ConnectivityState)
+	}
+	if _, err := cc.lastState.Picker.Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+		t.Fatalf("Pick after failure transition = %v, want ErrNoSubConnAvailable", err)
+	}
+}
+
+func TestUpdateSubConnStateIgnoresUnknownSubConn(t *testing.T) {
+	b, cc := newTestBalancer()
+
+	b.UpdateSubConnState(&fakeSubConn{}, balancer.SubConnState{ConnectivityState: connectivity.Ready})
+
+	if cc.lastState.Picker != nil {
+		t.Fatal("UpdateSubConnState regenerated the picker for a SubConn it never created")
+	}
+}
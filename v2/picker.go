@@ -0,0 +1,76 @@
+package v2
+
+import (
+	"google.golang.org/grpc/balancer"
+)
+
+// weightedSubConn pairs a SubConn with the bookkeeping Balancer uses to
+// track how many requests it currently has in flight.
+type weightedSubConn struct {
+	sc   balancer.SubConn
+	info *subConnInfo
+}
+
+// errPicker always returns the same error, used while no SubConn is Ready.
+type errPicker struct {
+	err error
+}
+
+func newErrPicker(err error) balancer.Picker {
+	return &errPicker{err: err}
+}
+
+func (p *errPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	return balancer.PickResult{}, p.err
+}
+
+// leastActivePicker selects, among the Ready SubConns captured at picker
+// creation time, the one with the fewest requests currently in flight. This
+// mirrors the pre-v2 ThroughputLoadBalancer selection strategy.
+type leastActivePicker struct {
+	subConns []*weightedSubConn
+}
+
+func newLeastActivePicker(subConns []*weightedSubConn) balancer.Picker {
+	return &leastActivePicker{subConns: subConns}
+}
+
+func (p *leastActivePicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	var chosen *weightedSubConn
+
+	for _, wsc := range p.subConns {
+		wsc.info.mu.Lock()
+		active := wsc.info.activeRequests
+		wsc.info.mu.Unlock()
+
+		if chosen == nil {
+			chosen = wsc
+			continue
+		}
+
+		chosen.info.mu.Lock()
+		chosenActive := chosen.info.activeRequests
+		chosen.info.mu.Unlock()
+
+		if active < chosenActive {
+			chosen = wsc
+		}
+	}
+
+	if chosen == nil {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+
+	chosen.info.mu.Lock()
+	chosen.info.activeRequests++
+	chosen.info.mu.Unlock()
+
+	return balancer.PickResult{
+		SubConn: chosen.sc,
+		Done: func(balancer.DoneInfo) {
+			chosen.info.mu.Lock()
+			chosen.info.activeRequests--
+			chosen.info.mu.Unlock()
+		},
+	}, nil
+}
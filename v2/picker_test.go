@@ -0,0 +1,51 @@
+package v2
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/balancer"
+)
+
+func TestLeastActivePickerChoosesLowerLoadAndDoneDecrements(t *testing.T) {
+	low := &fakeSubConn{}
+	high := &fakeSubConn{}
+	lowInfo := &subConnInfo{state: subConnStateReady, activeRequests: 1}
+	highInfo := &subConnInfo{state: subConnStateReady, activeRequests: 5}
+
+	p := newLeastActivePicker([]*weightedSubConn{
+		{sc: high, info: highInfo},
+		{sc: low, info: lowInfo},
+	})
+
+	res, err := p.Pick(balancer.PickInfo{})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	if res.SubConn != low {
+		t.Fatal("Pick chose the higher-load SubConn")
+	}
+	if lowInfo.activeRequests != 2 {
+		t.Fatalf("activeRequests after Pick = %d, want 2", lowInfo.activeRequests)
+	}
+
+	res.Done(balancer.DoneInfo{})
+	if lowInfo.activeRequests != 1 {
+		t.Fatalf("activeRequests after Done = %d, want 1", lowInfo.activeRequests)
+	}
+}
+
+func TestLeastActivePickerNoSubConns(t *testing.T) {
+	p := newLeastActivePicker(nil)
+
+	if _, err := p.Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+		t.Fatalf("Pick = %v, want ErrNoSubConnAvailable", err)
+	}
+}
+
+func TestErrPickerAlwaysReturnsItsError(t *testing.T) {
+	p := newErrPicker(balancer.ErrNoSubConnAvailable)
+
+	if _, err := p.Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+		t.Fatalf("Pick = %v, want ErrNoSubConnAvailable", err)
+	}
+}
@@ -0,0 +1,72 @@
+package v2
+
+import (
+	"net/url"
+	"strconv"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+func init() {
+	resolver.Register(newResolverBuilder())
+}
+
+// resolverBuilder resolves targets of the form "throughput:///host:port?n=32"
+// to a single backend address, stashing the requested SubConn count ("n")
+// in the resolver.State's Attributes so Balancer.UpdateClientConnState can
+// read it back via numAddrsFromTarget.
+type resolverBuilder struct{}
+
+func newResolverBuilder() resolver.Builder {
+	return &resolverBuilder{}
+}
+
+func (*resolverBuilder) Scheme() string {
+	return scheme
+}
+
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	r := &passthroughResolver{cc: cc}
+	r.ResolveNow(resolver.ResolveNowOptions{})
+
+	cc.UpdateState(resolver.State{
+		Addresses:  []resolver.Address{{Addr: target.Endpoint}},
+		Attributes: attributes.New("query", target.URL.RawQuery),
+	})
+
+	return r, nil
+}
+
+// passthroughResolver is returned once Build has reported the single endpoint
+// baked into the target; ResolveNow does not report it again and it does not
+// watch for changes.
+type passthroughResolver struct {
+	cc resolver.ClientConn
+}
+
+func (*passthroughResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (*passthroughResolver) Close() {}
+
+// numAddrsFromTarget extracts the "n" query parameter from the dial target,
+// e.g. 32 from "throughput:///target?n=32". It defaults to 1 when absent or
+// malformed.
+func numAddrsFromTarget(s resolver.State) int {
+	raw, ok := s.Attributes.Value("query").(string)
+	if !ok {
+		return 1
+	}
+
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return 1
+	}
+
+	n, err := strconv.Atoi(values.Get("n"))
+	if err != nil || n <= 0 {
+		return 1
+	}
+
+	return n
+}
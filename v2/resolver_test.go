@@ -0,0 +1,35 @@
+package v2
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+func stateWithQuery(q string) resolver.State {
+	return resolver.State{Attributes: attributes.New("query", q)}
+}
+
+func TestNumAddrsFromTarget(t *testing.T) {
+	cases := []struct {
+		name string
+		s    resolver.State
+		want int
+	}{
+		{"valid", stateWithQuery("n=32"), 32},
+		{"missing key", stateWithQuery(""), 1},
+		{"malformed n", stateWithQuery("n=notanumber"), 1},
+		{"zero n", stateWithQuery("n=0"), 1},
+		{"negative n", stateWithQuery("n=-4"), 1},
+		{"no attributes at all", resolver.State{}, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := numAddrsFromTarget(c.s); got != c.want {
+				t.Errorf("numAddrsFromTarget(%q) = %d, want %d", c.name, got, c.want)
+			}
+		})
+	}
+}